@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultAuditMaxBytes is the rotation threshold used when CODEAGENT_WRAPPER_AUDIT_LOG is set
+// but no explicit size limit is configured.
+const defaultAuditMaxBytes = 10 * 1024 * 1024
+
+// AuditRecord is the structured, append-only record written for every realCmd invocation. It
+// is the source of truth for "what did the wrapper run on behalf of the user" and is kept
+// separate from the human-readable transcript produced by extractMessageSummary.
+type AuditRecord struct {
+	Timestamp  time.Time      `json:"timestamp"`
+	Command    string         `json:"command"`
+	Args       []string       `json:"args"`
+	EnvKeys    []string       `json:"env_keys"`
+	WorkingDir string         `json:"working_dir"`
+	TaskID     string         `json:"task_id"`
+	ExitCode   int            `json:"exit_code"`
+	DurationMS int64          `json:"duration_ms"`
+	Summary    string         `json:"summary,omitempty"`
+	KeyOutput  string         `json:"key_output,omitempty"`
+	Extra      map[string]any `json:"extra,omitempty"`
+}
+
+// sensitiveEnvKeyPattern matches env var names that must never reach the audit log, even as
+// bare keys carrying no value.
+var sensitiveEnvKeyPattern = regexp.MustCompile(`(?i)(API_KEY|TOKEN|PASSWORD|SECRET)`)
+
+// redactEnvKeys returns the env var names present in a merged environment, never their values,
+// dropping any name that matches sensitiveEnvKeyPattern.
+func redactEnvKeys(env []string) []string {
+	keys := make([]string, 0, len(env))
+	for _, kv := range env {
+		name := kv
+		if idx := strings.IndexByte(kv, '='); idx >= 0 {
+			name = kv[:idx]
+		}
+		if sensitiveEnvKeyPattern.MatchString(name) {
+			continue
+		}
+		keys = append(keys, name)
+	}
+	return keys
+}
+
+// auditLogger appends AuditRecords to a JSON-lines file, rotating it by size.
+type auditLogger struct {
+	mu      sync.Mutex
+	path    string
+	maxSize int64
+}
+
+// defaultAuditLogger is configured from CODEAGENT_WRAPPER_AUDIT_LOG at process start. An empty
+// path makes every record() call a no-op, so audit logging is opt-in.
+var defaultAuditLogger = newAuditLogger(os.Getenv("CODEAGENT_WRAPPER_AUDIT_LOG"), defaultAuditMaxBytes)
+
+func newAuditLogger(path string, maxSize int64) *auditLogger {
+	if maxSize <= 0 {
+		maxSize = defaultAuditMaxBytes
+	}
+	return &auditLogger{path: path, maxSize: maxSize}
+}
+
+// record appends one AuditRecord as a JSON line, rotating the underlying file first if it has
+// grown past maxSize.
+func (a *auditLogger) record(rec AuditRecord) error {
+	if a == nil || a.path == "" {
+		return nil
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.rotateIfNeeded(); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(a.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("audit: open log: %w", err)
+	}
+	defer f.Close()
+
+	return json.NewEncoder(f).Encode(rec)
+}
+
+func (a *auditLogger) rotateIfNeeded() error {
+	info, err := os.Stat(a.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("audit: stat log: %w", err)
+	}
+	if info.Size() < a.maxSize {
+		return nil
+	}
+
+	rotated := a.path + "." + time.Now().UTC().Format("20060102T150405.000000000")
+	if err := os.Rename(a.path, rotated); err != nil {
+		return fmt.Errorf("audit: rotate log: %w", err)
+	}
+	return nil
+}
+
+// withAudit runs fn (the underlying cmd.Run), timing it and writing an AuditRecord regardless
+// of outcome, then returns fn's error untouched. cmd.Run blocks until the process exits, so
+// the timing and exit code it observes are already final.
+func (r *realCmd) withAudit(fn func() error) error {
+	if r == nil || r.cmd == nil {
+		return fn()
+	}
+
+	begin := time.Now()
+	runErr := fn()
+	r.writeAuditRecord(begin, time.Since(begin), runErr)
+	return runErr
+}
+
+// startWithAudit starts the process without blocking. Unlike Run, cmd.Start returns as soon
+// as the process is forked/exec'd, long before it has a real exit code or duration — so the
+// audit record is deferred to waitWithAudit, which times from the moment recorded here. If
+// Start itself fails, the process never ran and there will be no matching Wait, so the record
+// is written immediately.
+func (r *realCmd) startWithAudit() error {
+	if r == nil || r.cmd == nil {
+		return nil
+	}
+
+	r.auditStart = time.Now()
+	err := r.cmd.Start()
+	if err != nil {
+		r.writeAuditRecord(r.auditStart, time.Since(r.auditStart), err)
+		r.auditStart = time.Time{}
+	}
+	return err
+}
+
+// waitWithAudit blocks for the process started by startWithAudit and writes the audit record
+// against that start time, so ExitCode/DurationMS reflect the process's actual run rather than
+// Start's near-instant return. If Wait is called without a preceding successful Start (e.g. a
+// caller bypassing CommandRunner), it times from the point Wait itself was called.
+func (r *realCmd) waitWithAudit() error {
+	if r == nil || r.cmd == nil {
+		return nil
+	}
+
+	begin := r.auditStart
+	if begin.IsZero() {
+		begin = time.Now()
+	}
+	err := r.cmd.Wait()
+	r.writeAuditRecord(begin, time.Since(begin), err)
+	r.auditStart = time.Time{}
+	return err
+}
+
+func (r *realCmd) writeAuditRecord(begin time.Time, duration time.Duration, runErr error) {
+	exitCode := 0
+	if runErr != nil {
+		if exitErr, ok := runErr.(*exec.ExitError); ok {
+			exitCode = exitErr.ExitCode()
+		} else {
+			exitCode = -1
+		}
+	}
+
+	wd := r.cmd.Dir
+	if wd == "" {
+		if cwd, err := os.Getwd(); err == nil {
+			wd = cwd
+		}
+	}
+
+	taskID := r.taskID
+	if taskID == "" {
+		taskID = fallbackLogSuffix()
+	}
+
+	rec := AuditRecord{
+		Timestamp:  begin.UTC(),
+		Command:    r.cmd.Path,
+		Args:       append([]string(nil), r.cmd.Args...),
+		EnvKeys:    redactEnvKeys(r.cmd.Env),
+		WorkingDir: wd,
+		TaskID:     taskID,
+		ExitCode:   exitCode,
+		DurationMS: duration.Milliseconds(),
+	}
+	if r.output != nil {
+		extracted := DefaultExtractorChain.Extract(r.output.String(), 200)
+		rec.Summary = extracted.Summary
+		rec.KeyOutput = extracted.KeyOutput
+		rec.Extra = extracted.Extra
+	}
+
+	if err := defaultAuditLogger.record(rec); err != nil {
+		fmt.Fprintf(os.Stderr, "codeagent-wrapper: audit log: %v\n", err)
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRedactEnvKeys_DropsSensitiveNamesKeepsOthers(t *testing.T) {
+	got := redactEnvKeys([]string{
+		"PATH=/usr/bin",
+		"OPENAI_API_KEY=sk-secret",
+		"GITHUB_TOKEN=ghp-secret",
+		"DB_PASSWORD=hunter2",
+		"MY_SECRET_VALUE=x",
+		"HOME=/root",
+	})
+
+	want := map[string]bool{"PATH": true, "HOME": true}
+	for _, k := range got {
+		if !want[k] {
+			t.Fatalf("unexpected key survived redaction: %q", k)
+		}
+		delete(want, k)
+	}
+	if len(want) != 0 {
+		t.Fatalf("expected keys missing from result: %v", want)
+	}
+}
+
+func TestAuditLogger_RecordAppendsJSONLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	logger := newAuditLogger(path, defaultAuditMaxBytes)
+
+	if err := logger.record(AuditRecord{Command: "/bin/echo", TaskID: "task-1", ExitCode: 0}); err != nil {
+		t.Fatalf("record returned error: %v", err)
+	}
+	if err := logger.record(AuditRecord{Command: "/bin/echo", TaskID: "task-2", ExitCode: 1}); err != nil {
+		t.Fatalf("record returned error: %v", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open audit log: %v", err)
+	}
+	defer f.Close()
+
+	var taskIDs []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var rec AuditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			t.Fatalf("unmarshal audit record: %v", err)
+		}
+		taskIDs = append(taskIDs, rec.TaskID)
+	}
+	if len(taskIDs) != 2 || taskIDs[0] != "task-1" || taskIDs[1] != "task-2" {
+		t.Fatalf("unexpected records: %v", taskIDs)
+	}
+}
+
+func TestAuditLogger_RotatesWhenOverSize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	if err := os.WriteFile(path, []byte("existing contents\n"), 0o600); err != nil {
+		t.Fatalf("seed audit log: %v", err)
+	}
+
+	logger := newAuditLogger(path, 1)
+	if err := logger.record(AuditRecord{Command: "/bin/echo"}); err != nil {
+		t.Fatalf("record returned error: %v", err)
+	}
+
+	matches, err := filepath.Glob(path + ".*")
+	if err != nil {
+		t.Fatalf("glob rotated files: %v", err)
+	}
+	if len(matches) != 1 {
+		t.Fatalf("expected exactly one rotated file, got %v", matches)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("expected fresh audit log to exist after rotation: %v", err)
+	}
+}
+
+func TestAuditLogger_NoPathIsNoop(t *testing.T) {
+	logger := newAuditLogger("", defaultAuditMaxBytes)
+	if err := logger.record(AuditRecord{Command: "/bin/echo"}); err != nil {
+		t.Fatalf("expected no-op logger to return nil, got %v", err)
+	}
+}
+
+func TestRealCmd_RunWritesAuditRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	old := defaultAuditLogger
+	defaultAuditLogger = newAuditLogger(path, defaultAuditMaxBytes)
+	defer func() { defaultAuditLogger = old }()
+
+	cmd := exec.Command("echo", "Summary: did the thing")
+	var buf strings.Builder
+	cmd.Stdout = &buf
+	runner := &realCmd{cmd: cmd, taskID: "task-42", output: &buf}
+
+	if err := runner.Run(); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read audit log: %v", err)
+	}
+	var rec AuditRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		t.Fatalf("unmarshal audit record: %v", err)
+	}
+	if rec.TaskID != "task-42" {
+		t.Fatalf("expected task-42, got %q", rec.TaskID)
+	}
+	if rec.KeyOutput != "did the thing" {
+		t.Fatalf("expected extracted key output, got %q", rec.KeyOutput)
+	}
+}
+
+func TestRealCmd_StartThenWaitWritesAuditRecordAfterProcessExits(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	old := defaultAuditLogger
+	defaultAuditLogger = newAuditLogger(path, defaultAuditMaxBytes)
+	defer func() { defaultAuditLogger = old }()
+
+	cmd := exec.Command("sh", "-c", "sleep 0.2; exit 3")
+	runner := &realCmd{cmd: cmd, taskID: "task-async"}
+
+	if err := runner.Start(); err != nil {
+		t.Fatalf("Start returned error: %v", err)
+	}
+	if _, err := os.Stat(path); err == nil {
+		t.Fatalf("expected no audit record before Wait completes")
+	}
+
+	waitErr := runner.Wait()
+	if waitErr == nil {
+		t.Fatalf("expected Wait to surface the non-zero exit code as an error")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read audit log: %v", err)
+	}
+	var rec AuditRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		t.Fatalf("unmarshal audit record: %v", err)
+	}
+	if rec.ExitCode != 3 {
+		t.Fatalf("expected exit code 3, got %d", rec.ExitCode)
+	}
+	if rec.DurationMS < 150 {
+		t.Fatalf("expected duration to reflect the ~200ms sleep, got %dms", rec.DurationMS)
+	}
+}
+
+func TestRealCmd_StartFailureWritesAuditRecordImmediately(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	old := defaultAuditLogger
+	defaultAuditLogger = newAuditLogger(path, defaultAuditMaxBytes)
+	defer func() { defaultAuditLogger = old }()
+
+	cmd := exec.Command("/nonexistent/codeagent-wrapper-test-binary")
+	runner := &realCmd{cmd: cmd, taskID: "task-start-fail"}
+
+	if err := runner.Start(); err == nil {
+		t.Fatalf("expected Start to fail for a nonexistent binary")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("expected audit record written on Start failure: %v", err)
+	}
+	var rec AuditRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		t.Fatalf("unmarshal audit record: %v", err)
+	}
+	if rec.ExitCode != -1 {
+		t.Fatalf("expected exit code -1 for a start failure, got %d", rec.ExitCode)
+	}
+}
+
+func TestRealCmd_StartAndWaitNilReceiverDoesNotPanic(t *testing.T) {
+	var runner *realCmd
+	if err := runner.Start(); err != nil {
+		t.Fatalf("expected nil receiver Start to return nil, got %v", err)
+	}
+	if err := runner.Wait(); err != nil {
+		t.Fatalf("expected nil receiver Wait to return nil, got %v", err)
+	}
+}
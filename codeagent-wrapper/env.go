@@ -0,0 +1,238 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// EnvMode controls where SetEnv's base environment comes from before overrides are applied.
+type EnvMode int
+
+const (
+	// EnvModeMerge layers overrides on top of cmd.Env, keeping everything already there.
+	// This is the default and matches the wrapper's original behavior.
+	EnvModeMerge EnvMode = iota
+	// EnvModeReplace discards cmd.Env entirely; the subprocess sees only the overrides
+	// (plus whatever they interpolate in from each other).
+	EnvModeReplace
+	// EnvModeInheritAllowlist starts from the parent process environment, keeping only
+	// keys that match one of the configured allowlist patterns, then layers overrides on top.
+	EnvModeInheritAllowlist
+)
+
+// envRefPattern matches ${NAME} and ${NAME:-default} references inside an env value.
+var envRefPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// errEnvInterpolationCycle is wrapped with the offending key name; use errors.Is to detect it.
+var errEnvInterpolationCycle = errors.New("env interpolation cycle")
+
+// SetEnvMode selects the base environment SetEnv builds overrides on top of.
+func (r *realCmd) SetEnvMode(mode EnvMode) {
+	if r != nil {
+		r.envMode = mode
+	}
+}
+
+// SetEnvAllowlist configures the prefixes/globs consulted by EnvModeInheritAllowlist.
+// A pattern containing *, ?, or [ is matched with filepath.Match; anything else is a prefix.
+func (r *realCmd) SetEnvAllowlist(patterns []string) {
+	if r != nil {
+		r.envAllowlist = patterns
+	}
+}
+
+// SetEnvInterpolateFromParent allows ${NAME} references to fall back to the parent process's
+// environment when NAME isn't one of the command's own (merged) variables.
+func (r *realCmd) SetEnvInterpolateFromParent(enabled bool) {
+	if r != nil {
+		r.envInterpFromParent = enabled
+	}
+}
+
+// SetStrictEnvInterpolation makes unresolved ${NAME} references (no value, no default) an
+// error instead of expanding to an empty string.
+func (r *realCmd) SetStrictEnvInterpolation(strict bool) {
+	if r != nil {
+		r.envStrict = strict
+	}
+}
+
+// EnvErr returns the error, if any, raised by the most recent SetEnv call.
+func (r *realCmd) EnvErr() error {
+	if r == nil {
+		return nil
+	}
+	return r.envErr
+}
+
+func envKeyAllowed(name string, patterns []string) bool {
+	for _, p := range patterns {
+		if p == "" {
+			continue
+		}
+		if strings.ContainsAny(p, "*?[") {
+			if ok, err := filepath.Match(p, name); err == nil && ok {
+				return true
+			}
+			continue
+		}
+		if strings.HasPrefix(name, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func splitEnvPairs(env []string) map[string]string {
+	out := make(map[string]string, len(env))
+	for _, kv := range env {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		out[parts[0]] = parts[1]
+	}
+	return out
+}
+
+// SetEnv builds the command's environment from r.envMode's base, layers overrides on top
+// (dropping blank keys), then resolves ${NAME} / ${NAME:-default} references in every value.
+// Interpolation cycles always produce an error; an unresolved reference with no default errors
+// only when r.envStrict is set, otherwise it expands to "". On error, cmd.Env is left untouched
+// and the error is available via EnvErr.
+func (r *realCmd) SetEnv(overrides map[string]string) {
+	if r == nil || r.cmd == nil {
+		return
+	}
+	r.envErr = nil
+
+	// cmd.Env == nil is exec.Cmd's own signal to inherit the full parent environment; a
+	// merge-mode SetEnv must preserve that once overrides are applied below, rather than
+	// replacing "inherit everything" with "inherit nothing" just because rawMerged starts
+	// empty. This only affects the final cmd.Env, not which values are visible to ${NAME}
+	// interpolation (that stays gated behind envInterpFromParent).
+	inheritParent := r.envMode == EnvModeMerge && r.cmd.Env == nil
+
+	var rawMerged map[string]string
+	switch r.envMode {
+	case EnvModeReplace:
+		rawMerged = make(map[string]string)
+	case EnvModeInheritAllowlist:
+		rawMerged = make(map[string]string)
+		for k, v := range splitEnvPairs(os.Environ()) {
+			if envKeyAllowed(k, r.envAllowlist) {
+				rawMerged[k] = v
+			}
+		}
+	default:
+		rawMerged = splitEnvPairs(r.cmd.Env)
+	}
+
+	for k, v := range overrides {
+		if strings.TrimSpace(k) == "" {
+			continue
+		}
+		rawMerged[k] = v
+	}
+
+	keys := make([]string, 0, len(rawMerged))
+	for k := range rawMerged {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	resolved := make(map[string]string, len(rawMerged))
+	env := make([]string, 0, len(keys))
+	for _, k := range keys {
+		val, err := r.resolveEnvValue(k, rawMerged, resolved, map[string]bool{})
+		if err != nil {
+			r.envErr = err
+			return
+		}
+		env = append(env, k+"="+val)
+	}
+
+	if inheritParent {
+		merged := splitEnvPairs(os.Environ())
+		for _, kv := range env {
+			parts := strings.SplitN(kv, "=", 2)
+			merged[parts[0]] = parts[1]
+		}
+		keys = keys[:0]
+		for k := range merged {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		env = make([]string, 0, len(keys))
+		for _, k := range keys {
+			env = append(env, k+"="+merged[k])
+		}
+	}
+
+	r.cmd.Env = env
+}
+
+func (r *realCmd) resolveEnvValue(key string, rawMerged, resolved map[string]string, visiting map[string]bool) (string, error) {
+	if v, ok := resolved[key]; ok {
+		return v, nil
+	}
+	if visiting[key] {
+		return "", fmt.Errorf("%w: %s", errEnvInterpolationCycle, key)
+	}
+
+	raw, ok := rawMerged[key]
+	if !ok {
+		if r.envInterpFromParent {
+			if v, pok := os.LookupEnv(key); pok {
+				raw = v
+			} else if r.envStrict {
+				return "", fmt.Errorf("env interpolation: %q is not set", key)
+			}
+		} else if r.envStrict {
+			return "", fmt.Errorf("env interpolation: %q is not set", key)
+		}
+	}
+
+	visiting[key] = true
+	defer delete(visiting, key)
+
+	out := raw
+	for {
+		loc := envRefPattern.FindStringSubmatchIndex(out)
+		if loc == nil {
+			break
+		}
+		name := out[loc[2]:loc[3]]
+		hasDefault := loc[4] != -1
+		var def string
+		if hasDefault {
+			def = out[loc[6]:loc[7]]
+		}
+
+		val, err := r.resolveEnvValue(name, rawMerged, resolved, visiting)
+		if err != nil {
+			// A default breaks an apparent cycle (the common "PORT=${PORT:-8080}" idiom,
+			// which refers to itself only as a has-a-value check), so it takes priority
+			// over treating this as a hard cycle error.
+			switch {
+			case hasDefault:
+				val = def
+			case errors.Is(err, errEnvInterpolationCycle):
+				return "", err
+			case r.envStrict:
+				return "", err
+			default:
+				val = ""
+			}
+		}
+		out = out[:loc[0]] + val + out[loc[1]:]
+	}
+
+	resolved[key] = out
+	return out, nil
+}
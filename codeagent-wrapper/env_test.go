@@ -0,0 +1,173 @@
+package main
+
+import (
+	"os/exec"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func envMap(t *testing.T, cmd *exec.Cmd) map[string]string {
+	t.Helper()
+	out := make(map[string]string, len(cmd.Env))
+	for _, kv := range cmd.Env {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 {
+			t.Fatalf("unexpected env entry: %q", kv)
+		}
+		out[parts[0]] = parts[1]
+	}
+	return out
+}
+
+func TestSetEnv_MergeModeWithNilCmdEnvInheritsParentEnvironment(t *testing.T) {
+	t.Setenv("CODEAGENT_WRAPPER_TEST_PARENT_INHERIT", "present")
+
+	cmd := exec.Command("env") // cmd.Env is nil: exec.Cmd's own signal to inherit everything
+	runner := &realCmd{cmd: cmd}
+	runner.SetEnv(nil)
+
+	got := envMap(t, cmd)
+	if got["CODEAGENT_WRAPPER_TEST_PARENT_INHERIT"] != "present" {
+		t.Fatalf("expected nil cmd.Env to still inherit the parent environment, got %v", got)
+	}
+	if len(got) == 0 {
+		t.Fatalf("expected a non-empty environment, SetEnv(nil) must not wipe it")
+	}
+}
+
+func TestSetEnv_InterpolatesReferencesAndDefaults(t *testing.T) {
+	cmd := exec.Command("env")
+	runner := &realCmd{cmd: cmd}
+	runner.SetEnv(map[string]string{
+		"HOST":     "example.com",
+		"URL":      "https://${HOST}/api",
+		"PORT":     "${PORT:-8080}",
+		"GREETING": "hello ${MISSING}",
+	})
+
+	if err := runner.EnvErr(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := envMap(t, cmd)
+	if got["URL"] != "https://example.com/api" {
+		t.Fatalf("unexpected URL: %q", got["URL"])
+	}
+	if got["PORT"] != "8080" {
+		t.Fatalf("unexpected PORT: %q", got["PORT"])
+	}
+	if got["GREETING"] != "hello " {
+		t.Fatalf("expected missing ref to fall back to empty, got %q", got["GREETING"])
+	}
+}
+
+func TestSetEnv_StrictModeErrorsOnUnresolvedReference(t *testing.T) {
+	cmd := exec.Command("env")
+	runner := &realCmd{cmd: cmd}
+	runner.SetStrictEnvInterpolation(true)
+	runner.SetEnv(map[string]string{"GREETING": "hello ${MISSING}"})
+
+	if err := runner.EnvErr(); err == nil {
+		t.Fatalf("expected strict mode to error on unresolved reference")
+	}
+}
+
+func TestSetEnv_DetectsInterpolationCycles(t *testing.T) {
+	cmd := exec.Command("env")
+	runner := &realCmd{cmd: cmd}
+	runner.SetEnv(map[string]string{
+		"A": "${B}",
+		"B": "${A}",
+	})
+
+	err := runner.EnvErr()
+	if err == nil {
+		t.Fatalf("expected cycle error")
+	}
+	if !strings.Contains(err.Error(), "cycle") {
+		t.Fatalf("expected cycle error, got %v", err)
+	}
+}
+
+func TestSetEnv_ReplaceModeDropsExistingEnv(t *testing.T) {
+	cmd := exec.Command("env")
+	cmd.Env = []string{"EXISTING=keep"}
+	runner := &realCmd{cmd: cmd}
+	runner.SetEnvMode(EnvModeReplace)
+	runner.SetEnv(map[string]string{"ONLY": "this"})
+
+	got := envMap(t, cmd)
+	if _, ok := got["EXISTING"]; ok {
+		t.Fatalf("expected EnvModeReplace to drop cmd.Env, got EXISTING=%q", got["EXISTING"])
+	}
+	if got["ONLY"] != "this" {
+		t.Fatalf("unexpected ONLY: %q", got["ONLY"])
+	}
+}
+
+func TestSetEnv_InheritAllowlistOnlyKeepsMatchingPrefixes(t *testing.T) {
+	t.Setenv("CODEAGENT_WRAPPER_TEST_ALLOWED", "yes")
+	t.Setenv("CODEAGENT_WRAPPER_TEST_BLOCKED", "no")
+
+	cmd := exec.Command("env")
+	runner := &realCmd{cmd: cmd}
+	runner.SetEnvMode(EnvModeInheritAllowlist)
+	runner.SetEnvAllowlist([]string{"CODEAGENT_WRAPPER_TEST_ALLOWED"})
+	runner.SetEnv(nil)
+
+	got := envMap(t, cmd)
+	if got["CODEAGENT_WRAPPER_TEST_ALLOWED"] != "yes" {
+		t.Fatalf("expected allowlisted var to be inherited, got %q", got["CODEAGENT_WRAPPER_TEST_ALLOWED"])
+	}
+	if _, ok := got["CODEAGENT_WRAPPER_TEST_BLOCKED"]; ok {
+		t.Fatalf("expected non-allowlisted var to be dropped")
+	}
+}
+
+func TestSetEnv_ParentFallbackOnlyWhenEnabled(t *testing.T) {
+	t.Setenv("CODEAGENT_WRAPPER_TEST_PARENT", "from-parent")
+
+	cmd := exec.Command("env")
+	runner := &realCmd{cmd: cmd}
+	runner.SetEnv(map[string]string{"GREETING": "hi ${CODEAGENT_WRAPPER_TEST_PARENT}"})
+	if got := envMap(t, cmd)["GREETING"]; got != "hi " {
+		t.Fatalf("expected parent fallback disabled by default, got %q", got)
+	}
+
+	runner.SetEnvInterpolateFromParent(true)
+	runner.SetEnv(map[string]string{"GREETING": "hi ${CODEAGENT_WRAPPER_TEST_PARENT}"})
+	if got := envMap(t, cmd)["GREETING"]; got != "hi from-parent" {
+		t.Fatalf("expected parent fallback, got %q", got)
+	}
+}
+
+func TestEnvKeyAllowed_SupportsGlobsAndPrefixes(t *testing.T) {
+	if !envKeyAllowed("AWS_ACCESS_KEY", []string{"AWS_"}) {
+		t.Fatalf("expected prefix match")
+	}
+	if !envKeyAllowed("AWS_ACCESS_KEY", []string{"AWS_*"}) {
+		t.Fatalf("expected glob match")
+	}
+	if envKeyAllowed("GCP_TOKEN", []string{"AWS_"}) {
+		t.Fatalf("expected no match")
+	}
+}
+
+// Sanity check that plain merge mode (no interpolation syntax) is unaffected, matching the
+// original SetEnv contract exercised in coverage_extra_test.go.
+func TestSetEnv_PlainValuesAreUntouched(t *testing.T) {
+	cmd := exec.Command("env")
+	cmd.Env = []string{"KEEP=1"}
+	runner := &realCmd{cmd: cmd}
+	runner.SetEnv(map[string]string{"ADDED": "2"})
+
+	keys := make([]string, 0, len(cmd.Env))
+	for _, kv := range cmd.Env {
+		keys = append(keys, strings.SplitN(kv, "=", 2)[0])
+	}
+	sorted := append([]string(nil), keys...)
+	sort.Strings(sorted)
+	if strings.Join(keys, ",") != strings.Join(sorted, ",") {
+		t.Fatalf("expected sorted keys, got %v", keys)
+	}
+}
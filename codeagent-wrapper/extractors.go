@@ -0,0 +1,332 @@
+package main
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ExtractResult is what a SummaryExtractor produces for one message: display text plus
+// whatever structured fields it recognized along the way (file paths, tool names, commit
+// SHAs, test counts, error snippets, ...).
+type ExtractResult struct {
+	Summary   string
+	KeyOutput string
+	Extra     map[string]any
+}
+
+// SummaryExtractor turns a raw agent message into display text plus structured metadata. ok
+// is false when the extractor doesn't recognize this message's shape, so the chain should
+// fall through to the next one.
+type SummaryExtractor interface {
+	Extract(msg string, maxLen int) (result ExtractResult, ok bool)
+}
+
+// ExtractorChain tries extractors in order. The Summary/KeyOutput come from the first
+// extractor that recognizes the message; Extra metadata is merged from every extractor that
+// ran, first-writer-wins per key, so an earlier extractor's structured finds aren't lost just
+// because a later one supplied the headline summary.
+type ExtractorChain []SummaryExtractor
+
+// DefaultExtractorChain is used by extractMessageSummary/extractKeyOutput and by the audit
+// log. HeuristicExtractor is always last and always matches, so it's a safe catch-all.
+var DefaultExtractorChain = ExtractorChain{
+	JSONEnvelopeExtractor{},
+	MarkdownExtractor{},
+	HeuristicExtractor{},
+}
+
+// RegisterExtractor adds extractor to DefaultExtractorChain, ahead of the built-in heuristic
+// catch-all but after anything already registered.
+func RegisterExtractor(extractor SummaryExtractor) {
+	last := len(DefaultExtractorChain) - 1
+	if last < 0 {
+		DefaultExtractorChain = ExtractorChain{extractor}
+		return
+	}
+	head := append(ExtractorChain{}, DefaultExtractorChain[:last]...)
+	DefaultExtractorChain = append(append(head, extractor), DefaultExtractorChain[last:]...)
+}
+
+// Extract runs every extractor in the chain, taking Summary/KeyOutput from the first match
+// and merging Extra metadata from all of them.
+func (c ExtractorChain) Extract(msg string, maxLen int) ExtractResult {
+	var final ExtractResult
+	haveSummary := false
+
+	for _, ex := range c {
+		res, ok := ex.Extract(msg, maxLen)
+		for k, v := range res.Extra {
+			if final.Extra == nil {
+				final.Extra = make(map[string]any)
+			}
+			if _, exists := final.Extra[k]; !exists {
+				final.Extra[k] = v
+			}
+		}
+		if ok && !haveSummary {
+			final.Summary = res.Summary
+			final.KeyOutput = res.KeyOutput
+			haveSummary = true
+		}
+	}
+	return final
+}
+
+// HeuristicExtractor is the original, always-matching extractor: skip fences/dashes, prefer
+// a "Summary:" line, otherwise the first line long enough to be meaningful.
+type HeuristicExtractor struct{}
+
+func (HeuristicExtractor) Extract(msg string, maxLen int) (ExtractResult, bool) {
+	return ExtractResult{
+		Summary:   extractMessageSummaryPlain(msg, maxLen),
+		KeyOutput: extractKeyOutputPlain(msg, maxLen),
+		Extra:     detectExtra(msg),
+	}, true
+}
+
+func extractMessageSummaryPlain(msg string, maxLen int) string {
+	var noise []string
+	for _, line := range strings.Split(msg, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			continue
+		}
+		if trimmed == "```" || trimmed == "---" {
+			noise = append(noise, trimmed)
+			continue
+		}
+		return truncateText(trimmed, maxLen)
+	}
+	return truncateText(strings.Join(noise, "\n"), maxLen)
+}
+
+func extractKeyOutputPlain(msg string, maxLen int) string {
+	lines := strings.Split(msg, "\n")
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "Summary:") {
+			return truncateText(strings.TrimSpace(strings.TrimPrefix(trimmed, "Summary:")), maxLen)
+		}
+	}
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if len(trimmed) > 20 {
+			return truncateText(trimmed, maxLen)
+		}
+	}
+	return truncateText(strings.TrimSpace(msg), maxLen)
+}
+
+// MarkdownExtractor matches messages that look like markdown (a heading, a fence, or a
+// bullet) and, unlike HeuristicExtractor, treats headings as noise and prioritizes the first
+// bullet over plain prose.
+type MarkdownExtractor struct{}
+
+func (MarkdownExtractor) Extract(msg string, maxLen int) (ExtractResult, bool) {
+	if !looksLikeMarkdown(msg) {
+		return ExtractResult{}, false
+	}
+	return ExtractResult{
+		Summary:   extractMarkdownSummary(msg, maxLen),
+		KeyOutput: extractMarkdownKeyOutput(msg, maxLen),
+		Extra:     detectExtra(msg),
+	}, true
+}
+
+func looksLikeMarkdown(msg string) bool {
+	for _, line := range strings.Split(msg, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "#") || trimmed == "```" ||
+			strings.HasPrefix(trimmed, "- ") || strings.HasPrefix(trimmed, "* ") {
+			return true
+		}
+	}
+	return false
+}
+
+func stripBulletPrefix(s string) string {
+	return strings.TrimPrefix(strings.TrimPrefix(s, "- "), "* ")
+}
+
+func extractMarkdownSummary(msg string, maxLen int) string {
+	var bullet string
+	var noise []string
+	for _, line := range strings.Split(msg, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			continue
+		}
+		if trimmed == "```" || trimmed == "---" || strings.HasPrefix(trimmed, "#") {
+			noise = append(noise, trimmed)
+			continue
+		}
+		if bullet == "" && (strings.HasPrefix(trimmed, "- ") || strings.HasPrefix(trimmed, "* ")) {
+			bullet = stripBulletPrefix(trimmed)
+			continue
+		}
+		if bullet == "" {
+			return truncateText(trimmed, maxLen)
+		}
+	}
+	if bullet != "" {
+		return truncateText(bullet, maxLen)
+	}
+	return truncateText(strings.Join(noise, "\n"), maxLen)
+}
+
+func extractMarkdownKeyOutput(msg string, maxLen int) string {
+	lines := strings.Split(msg, "\n")
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "Summary:") {
+			return truncateText(strings.TrimSpace(strings.TrimPrefix(trimmed, "Summary:")), maxLen)
+		}
+	}
+
+	var bullet string
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || trimmed == "```" || trimmed == "---" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "- ") || strings.HasPrefix(trimmed, "* ") {
+			if content := stripBulletPrefix(trimmed); bullet == "" && len(content) > 20 {
+				bullet = content
+			}
+			continue
+		}
+		if bullet == "" && len(trimmed) > 20 {
+			return truncateText(trimmed, maxLen)
+		}
+	}
+	if bullet != "" {
+		return truncateText(bullet, maxLen)
+	}
+	return truncateText(strings.TrimSpace(msg), maxLen)
+}
+
+// jsonEnvelope is the shape JSONEnvelopeExtractor recognizes: a JSON object carrying its own
+// pre-computed summary fields, so there's no heuristic guessing to do.
+type jsonEnvelope struct {
+	Summary      string   `json:"summary"`
+	FilesChanged []string `json:"files_changed"`
+	Tests        *int     `json:"tests"`
+	Error        string   `json:"error"`
+}
+
+// JSONEnvelopeExtractor matches messages that are themselves a JSON object with at least one
+// known field (summary/files_changed/tests/error), using those fields directly instead of
+// guessing from prose.
+type JSONEnvelopeExtractor struct{}
+
+func (JSONEnvelopeExtractor) Extract(msg string, maxLen int) (ExtractResult, bool) {
+	trimmed := strings.TrimSpace(msg)
+	if !strings.HasPrefix(trimmed, "{") {
+		return ExtractResult{}, false
+	}
+
+	var envelope jsonEnvelope
+	if err := json.Unmarshal([]byte(trimmed), &envelope); err != nil {
+		return ExtractResult{}, false
+	}
+	if envelope.Summary == "" && len(envelope.FilesChanged) == 0 && envelope.Tests == nil && envelope.Error == "" {
+		return ExtractResult{}, false
+	}
+
+	extra := make(map[string]any)
+	if len(envelope.FilesChanged) > 0 {
+		extra["file_paths"] = envelope.FilesChanged
+	}
+	if envelope.Tests != nil {
+		extra["test_count"] = *envelope.Tests
+	}
+	if envelope.Error != "" {
+		extra["error_snippets"] = []string{envelope.Error}
+	}
+	if len(extra) == 0 {
+		extra = nil
+	}
+
+	display := envelope.Summary
+	if display == "" {
+		display = envelope.Error
+	}
+	return ExtractResult{
+		Summary:   truncateText(display, maxLen),
+		KeyOutput: truncateText(display, maxLen),
+		Extra:     extra,
+	}, display != ""
+}
+
+var (
+	filePathPattern  = regexp.MustCompile(`\b[\w./-]+/[\w.-]+\.[a-zA-Z]{1,6}\b`)
+	commitSHAPattern = regexp.MustCompile(`\b[0-9a-f]*[a-f][0-9a-f]{6,39}\b`)
+	testCountPattern = regexp.MustCompile(`(?i)(\d+)\s+tests?\b`)
+	toolNamePattern  = regexp.MustCompile("`([A-Za-z_][\\w.:/-]*)`")
+)
+
+// detectExtra scans a raw message for structured fields a human-readable summary would
+// otherwise throw away: file paths, tool names, commit SHAs, test counts, error lines.
+func detectExtra(msg string) map[string]any {
+	extra := make(map[string]any)
+
+	if paths := dedupeStrings(filePathPattern.FindAllString(msg, -1)); len(paths) > 0 {
+		extra["file_paths"] = paths
+	}
+	if shas := dedupeStrings(commitSHAPattern.FindAllString(msg, -1)); len(shas) > 0 {
+		extra["commit_shas"] = shas
+	}
+	if tools := dedupeStrings(matchGroups(toolNamePattern, msg)); len(tools) > 0 {
+		extra["tool_names"] = tools
+	}
+	if m := testCountPattern.FindStringSubmatch(msg); m != nil {
+		if n, err := strconv.Atoi(m[1]); err == nil {
+			extra["test_count"] = n
+		}
+	}
+
+	var errs []string
+	for _, line := range strings.Split(msg, "\n") {
+		if strings.Contains(strings.ToLower(line), "error") {
+			errs = append(errs, strings.TrimSpace(line))
+		}
+	}
+	if len(errs) > 0 {
+		extra["error_snippets"] = dedupeStrings(errs)
+	}
+
+	if len(extra) == 0 {
+		return nil
+	}
+	return extra
+}
+
+func matchGroups(re *regexp.Regexp, s string) []string {
+	matches := re.FindAllStringSubmatch(s, -1)
+	out := make([]string, 0, len(matches))
+	for _, m := range matches {
+		out = append(out, m[1])
+	}
+	return out
+}
+
+func dedupeStrings(in []string) []string {
+	if len(in) == 0 {
+		return nil
+	}
+	seen := make(map[string]bool, len(in))
+	out := make([]string, 0, len(in))
+	for _, s := range in {
+		if seen[s] {
+			continue
+		}
+		seen[s] = true
+		out = append(out, s)
+	}
+	return out
+}
@@ -0,0 +1,100 @@
+package main
+
+import "testing"
+
+func TestJSONEnvelopeExtractor_UsesKnownFieldsDirectly(t *testing.T) {
+	msg := `{"summary":"Added JWT middleware","files_changed":["auth/jwt.go","auth/jwt_test.go"],"tests":7}`
+	res, ok := JSONEnvelopeExtractor{}.Extract(msg, 200)
+	if !ok {
+		t.Fatalf("expected JSON envelope to be recognized")
+	}
+	if res.Summary != "Added JWT middleware" {
+		t.Fatalf("unexpected summary: %q", res.Summary)
+	}
+	if n, _ := res.Extra["test_count"].(int); n != 7 {
+		t.Fatalf("unexpected test_count: %v", res.Extra["test_count"])
+	}
+	files, _ := res.Extra["file_paths"].([]string)
+	if len(files) != 2 {
+		t.Fatalf("unexpected file_paths: %v", res.Extra["file_paths"])
+	}
+}
+
+func TestJSONEnvelopeExtractor_IgnoresUnrelatedJSON(t *testing.T) {
+	_, ok := JSONEnvelopeExtractor{}.Extract(`{"foo":"bar"}`, 200)
+	if ok {
+		t.Fatalf("expected extractor to decline JSON without known fields")
+	}
+	_, ok = JSONEnvelopeExtractor{}.Extract("not json at all", 200)
+	if ok {
+		t.Fatalf("expected extractor to decline non-JSON messages")
+	}
+}
+
+func TestMarkdownExtractor_PrioritizesFirstBullet(t *testing.T) {
+	msg := "# Changes\n- Added retry logic\n- Tidied up logging\n"
+	res, ok := MarkdownExtractor{}.Extract(msg, 200)
+	if !ok {
+		t.Fatalf("expected markdown message to be recognized")
+	}
+	if res.Summary != "Added retry logic" {
+		t.Fatalf("unexpected summary: %q", res.Summary)
+	}
+}
+
+func TestMarkdownExtractor_DeclinesPlainProse(t *testing.T) {
+	_, ok := MarkdownExtractor{}.Extract("just a plain sentence with no markup", 200)
+	if ok {
+		t.Fatalf("expected plain prose to be declined")
+	}
+}
+
+func TestDetectExtra_FindsFilePathsAndTestCounts(t *testing.T) {
+	msg := "Updated pkg/auth/jwt.go and ran 12 tests, all green. commit abcdef1 pushed."
+	extra := detectExtra(msg)
+	if extra == nil {
+		t.Fatalf("expected non-nil extra")
+	}
+	if n, _ := extra["test_count"].(int); n != 12 {
+		t.Fatalf("unexpected test_count: %v", extra["test_count"])
+	}
+	paths, _ := extra["file_paths"].([]string)
+	if len(paths) != 1 || paths[0] != "pkg/auth/jwt.go" {
+		t.Fatalf("unexpected file_paths: %v", paths)
+	}
+}
+
+func TestExtractorChain_RegisterExtractorRunsBeforeHeuristicFallback(t *testing.T) {
+	original := DefaultExtractorChain
+	defer func() { DefaultExtractorChain = original }()
+
+	RegisterExtractor(stubExtractor{summary: "stub summary", ok: true})
+
+	if got := extractMessageSummary("anything", 200); got != "stub summary" {
+		t.Fatalf("expected registered extractor to win, got %q", got)
+	}
+}
+
+func TestExtractorChain_MergesExtraFromNonWinningExtractors(t *testing.T) {
+	chain := ExtractorChain{
+		stubExtractor{ok: false, extra: map[string]any{"from_first": "a"}},
+		stubExtractor{summary: "winner", ok: true, extra: map[string]any{"from_second": "b"}},
+	}
+	res := chain.Extract("msg", 200)
+	if res.Summary != "winner" {
+		t.Fatalf("unexpected summary: %q", res.Summary)
+	}
+	if res.Extra["from_first"] != "a" || res.Extra["from_second"] != "b" {
+		t.Fatalf("expected merged extra, got %v", res.Extra)
+	}
+}
+
+type stubExtractor struct {
+	summary string
+	ok      bool
+	extra   map[string]any
+}
+
+func (s stubExtractor) Extract(msg string, maxLen int) (ExtractResult, bool) {
+	return ExtractResult{Summary: s.summary, KeyOutput: s.summary, Extra: s.extra}, s.ok
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// CommandRunner decouples the wrapper from os/exec so tests can substitute a fake
+// implementation without shelling out.
+type CommandRunner interface {
+	Run() error
+	Start() error
+	Wait() error
+	SetEnv(map[string]string)
+}
+
+// realCmd adapts *exec.Cmd to CommandRunner and is the implementation used outside tests.
+type realCmd struct {
+	cmd    *exec.Cmd
+	taskID string
+
+	// output, when set, captures the subprocess's combined stdout so Run/Start can derive
+	// a summary and key output for the audit log. Optional.
+	output *strings.Builder
+
+	// Env interpolation/mode settings consumed by SetEnv; see env.go.
+	envMode             EnvMode
+	envAllowlist        []string
+	envInterpFromParent bool
+	envStrict           bool
+	envErr              error
+
+	// auditStart records when Start() was called, so Wait() can write the audit record
+	// against the process's actual run time instead of Start()'s near-instant return; see
+	// audit.go.
+	auditStart time.Time
+}
+
+func (r *realCmd) Run() error {
+	return r.withAudit(r.cmd.Run)
+}
+
+func (r *realCmd) Start() error {
+	return r.startWithAudit()
+}
+
+func (r *realCmd) Wait() error {
+	return r.waitWithAudit()
+}
+
+const summaryEllipsis = "..."
+
+func truncateText(s string, maxLen int) string {
+	if maxLen <= 0 || len(s) <= maxLen {
+		return s
+	}
+	if maxLen <= len(summaryEllipsis) {
+		return s[:maxLen]
+	}
+	return s[:maxLen-len(summaryEllipsis)] + summaryEllipsis
+}
+
+// extractMessageSummary picks a single display line out of an agent message. It runs
+// DefaultExtractorChain so JSON-envelope and markdown-aware extractors get a chance before
+// falling back to the plain heuristic; see extractors.go.
+func extractMessageSummary(msg string, maxLen int) string {
+	return DefaultExtractorChain.Extract(msg, maxLen).Summary
+}
+
+// extractKeyOutput picks the most load-bearing line out of an agent message, e.g. for audit
+// records. See extractMessageSummary and extractors.go.
+func extractKeyOutput(msg string, maxLen int) string {
+	return DefaultExtractorChain.Extract(msg, maxLen).KeyOutput
+}
+
+func main() {
+	args := os.Args[1:]
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: codeagent-wrapper <command> [args...]")
+		os.Exit(2)
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stderr = os.Stderr
+
+	var out strings.Builder
+	cmd.Stdout = &out
+
+	runner := &realCmd{cmd: cmd, taskID: fallbackLogSuffix(), output: &out}
+	runner.SetEnv(nil)
+
+	if err := runner.Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "codeagent-wrapper: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("[%s] %s\n", runner.taskID, extractMessageSummary(out.String(), 200))
+}